@@ -0,0 +1,83 @@
+// Package client defines the subset of the OpenShift API the registry
+// needs in order to read and persist image metadata. It exists mainly so
+// that the registry's storage driver can be exercised against a fake
+// implementation in tests without pulling in the full generated clientset.
+package client
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// Interface is the set of OpenShift APIs the registry talks to.
+type Interface interface {
+	ImageStreamsNamespacer
+	ImageStreamImagesNamespacer
+	ImageStreamImportsNamespacer
+	ImageStreamTagsNamespacer
+	ImageStreamSecretsNamespacer
+	LimitRangesGetter
+}
+
+// ImageStreamsNamespacer has methods to work with ImageStream resources in a namespace.
+type ImageStreamsNamespacer interface {
+	ImageStreams(namespace string) ImageStreamInterface
+}
+
+// ImageStreamInterface exposes methods on ImageStream resources.
+type ImageStreamInterface interface {
+	Get(name string) (*imageapi.ImageStream, error)
+	Create(stream *imageapi.ImageStream) (*imageapi.ImageStream, error)
+	Update(stream *imageapi.ImageStream) (*imageapi.ImageStream, error)
+}
+
+// ImageStreamImagesNamespacer has methods to work with ImageStreamImage resources in a namespace.
+type ImageStreamImagesNamespacer interface {
+	ImageStreamImages(namespace string) ImageStreamImageInterface
+}
+
+// ImageStreamImageInterface exposes methods on ImageStreamImage resources.
+type ImageStreamImageInterface interface {
+	Get(name, id string) (*imageapi.ImageStreamImage, error)
+}
+
+// ImageStreamImportsNamespacer has methods to work with ImageStreamImport resources in a namespace.
+type ImageStreamImportsNamespacer interface {
+	ImageStreamImports(namespace string) ImageStreamImportInterface
+}
+
+// ImageStreamImportInterface exposes methods on ImageStreamImport resources.
+type ImageStreamImportInterface interface {
+	Create(isi *imageapi.ImageStreamImport) (*imageapi.ImageStreamImport, error)
+}
+
+// ImageStreamTagsNamespacer has methods to work with ImageStreamTag resources in a namespace.
+type ImageStreamTagsNamespacer interface {
+	ImageStreamTags(namespace string) ImageStreamTagInterface
+}
+
+// ImageStreamTagInterface exposes methods on ImageStreamTag resources.
+type ImageStreamTagInterface interface {
+	Get(name, tag string) (*imageapi.ImageStreamTag, error)
+}
+
+// ImageStreamSecretsNamespacer has methods to work with the pull secrets of an ImageStream.
+type ImageStreamSecretsNamespacer interface {
+	ImageStreamSecrets(namespace string) ImageStreamSecretInterface
+}
+
+// ImageStreamSecretInterface exposes methods for retrieving ImageStream pull secrets.
+type ImageStreamSecretInterface interface {
+	Secrets(name string, options kapi.ListOptions) (*kapi.SecretList, error)
+}
+
+// LimitRangesGetter has a method to retrieve LimitRange resources in a namespace.
+type LimitRangesGetter interface {
+	LimitRanges(namespace string) LimitRangeInterface
+}
+
+// LimitRangeInterface exposes methods on LimitRange resources.
+type LimitRangeInterface interface {
+	List(options kapi.ListOptions) (*kapi.LimitRangeList, error)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	manifestCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "imageregistry",
+		Subsystem: "manifestcache",
+		Name:      "hits_total",
+		Help:      "Number of manifest Get calls served from the in-process manifest cache.",
+	})
+	manifestCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "imageregistry",
+		Subsystem: "manifestcache",
+		Name:      "misses_total",
+		Help:      "Number of manifest Get calls that had to fetch the manifest from storage.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(manifestCacheHits)
+	prometheus.MustRegister(manifestCacheMisses)
+}
+
+// cachedManifest is the unit of storage kept by ManifestCache: the
+// canonical, content-addressable encoding of a manifest together with the
+// media type and descriptor needed to interpret it without going back to
+// the underlying manifest store.
+type cachedManifest struct {
+	mediaType  string
+	payload    []byte
+	descriptor distribution.Descriptor
+}
+
+type manifestCacheEntry struct {
+	dgst  digest.Digest
+	cache cachedManifest
+}
+
+// ManifestCache is a bounded, in-process LRU cache of manifest payloads
+// keyed by digest. It is created once and shared by every repository this
+// process serves (see the registry's App singleton), so that repeated pulls
+// of a popular image don't each pay the cost of a large-payload round trip
+// to the OpenShift API server to re-fetch or reconstruct the same manifest.
+type ManifestCache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[digest.Digest]*list.Element
+}
+
+// NewManifestCache creates a ManifestCache holding at most maxEntries
+// manifests and at most maxBytes of payload data, evicting the least
+// recently used entry once either limit is exceeded.
+func NewManifestCache(maxEntries int, maxBytes int64) *ManifestCache {
+	return &ManifestCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    make(map[digest.Digest]*list.Element),
+	}
+}
+
+// Get returns the cached manifest for dgst, if any, and marks it as most
+// recently used.
+func (c *ManifestCache) Get(dgst digest.Digest) (cachedManifest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[dgst]
+	if !ok {
+		return cachedManifest{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*manifestCacheEntry).cache, true
+}
+
+// Add stores a manifest in the cache, evicting least recently used entries
+// as needed to respect maxEntries and maxBytes. A payload larger than
+// maxBytes on its own is not cached.
+func (c *ManifestCache) Add(dgst digest.Digest, mediaType string, payload []byte, desc distribution.Descriptor) {
+	if c.maxEntries <= 0 || int64(len(payload)) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := cachedManifest{mediaType: mediaType, payload: payload, descriptor: desc}
+
+	if elem, ok := c.entries[dgst]; ok {
+		c.size -= int64(len(elem.Value.(*manifestCacheEntry).cache.payload))
+		elem.Value.(*manifestCacheEntry).cache = entry
+		c.order.MoveToFront(elem)
+	} else {
+		elem := c.order.PushFront(&manifestCacheEntry{dgst: dgst, cache: entry})
+		c.entries[dgst] = elem
+	}
+	c.size += int64(len(payload))
+
+	for c.order.Len() > c.maxEntries || c.size > c.maxBytes {
+		c.evictOldest()
+	}
+}
+
+// Remove evicts dgst from the cache, if present. Callers must remove a
+// manifest's entry when it is deleted from the underlying store, or Get
+// would keep serving the stale payload straight from cache indefinitely.
+func (c *ManifestCache) Remove(dgst digest.Digest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[dgst]
+	if !ok {
+		return
+	}
+	c.order.Remove(elem)
+	delete(c.entries, dgst)
+	c.size -= int64(len(elem.Value.(*manifestCacheEntry).cache.payload))
+}
+
+func (c *ManifestCache) evictOldest() {
+	oldest := c.order.Back()
+	if oldest == nil {
+		return
+	}
+	c.order.Remove(oldest)
+	entry := oldest.Value.(*manifestCacheEntry)
+	delete(c.entries, entry.dgst)
+	c.size -= int64(len(entry.cache.payload))
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+)
+
+func digestFor(s string) digest.Digest {
+	return digest.FromBytes([]byte(s))
+}
+
+func TestManifestCacheGetMiss(t *testing.T) {
+	c := NewManifestCache(10, 1000)
+
+	if _, ok := c.Get(digestFor("a")); ok {
+		t.Fatalf("expected miss on empty cache")
+	}
+}
+
+func TestManifestCacheAddAndGet(t *testing.T) {
+	c := NewManifestCache(10, 1000)
+	dgst := digestFor("a")
+
+	c.Add(dgst, "application/vnd.docker.distribution.manifest.v2+json", []byte("payload"), distribution.Descriptor{Digest: dgst})
+
+	cached, ok := c.Get(dgst)
+	if !ok {
+		t.Fatalf("expected hit after Add")
+	}
+	if string(cached.payload) != "payload" {
+		t.Errorf("got payload %q, want %q", cached.payload, "payload")
+	}
+}
+
+func TestManifestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewManifestCache(2, 1000)
+
+	a, b, cc := digestFor("a"), digestFor("b"), digestFor("c")
+	c.Add(a, "", []byte("a"), distribution.Descriptor{})
+	c.Add(b, "", []byte("b"), distribution.Descriptor{})
+
+	// touch a so b becomes the least recently used entry
+	if _, ok := c.Get(a); !ok {
+		t.Fatalf("expected hit for a")
+	}
+
+	c.Add(cc, "", []byte("c"), distribution.Descriptor{})
+
+	if _, ok := c.Get(b); ok {
+		t.Errorf("expected b to have been evicted")
+	}
+	if _, ok := c.Get(a); !ok {
+		t.Errorf("expected a to still be cached")
+	}
+	if _, ok := c.Get(cc); !ok {
+		t.Errorf("expected c to be cached")
+	}
+}
+
+func TestManifestCacheOverwriteInPlaceAccounting(t *testing.T) {
+	c := NewManifestCache(10, 10)
+	dgst := digestFor("a")
+
+	c.Add(dgst, "", []byte("12345"), distribution.Descriptor{})
+	c.Add(dgst, "", []byte("1234567890"), distribution.Descriptor{})
+
+	cached, ok := c.Get(dgst)
+	if !ok {
+		t.Fatalf("expected entry to still be cached after overwrite")
+	}
+	if len(cached.payload) != 10 {
+		t.Errorf("got payload len %d, want 10", len(cached.payload))
+	}
+	if c.size != 10 {
+		t.Errorf("got tracked size %d, want 10 (overwrite must release the old payload's bytes)", c.size)
+	}
+}
+
+func TestManifestCacheRejectsPayloadLargerThanMaxBytes(t *testing.T) {
+	c := NewManifestCache(10, 5)
+	dgst := digestFor("a")
+
+	c.Add(dgst, "", []byte("this payload is too big"), distribution.Descriptor{})
+
+	if _, ok := c.Get(dgst); ok {
+		t.Fatalf("expected oversized payload not to be cached")
+	}
+}
+
+func TestManifestCacheEvictsOnMaxBytes(t *testing.T) {
+	c := NewManifestCache(10, 10)
+
+	a, b := digestFor("a"), digestFor("b")
+	c.Add(a, "", []byte("123456"), distribution.Descriptor{})
+	c.Add(b, "", []byte("7890"), distribution.Descriptor{})
+
+	if _, ok := c.Get(a); ok {
+		t.Errorf("expected a to have been evicted once total size exceeded maxBytes")
+	}
+	if _, ok := c.Get(b); !ok {
+		t.Errorf("expected b to still be cached")
+	}
+}
+
+func TestManifestCacheRemove(t *testing.T) {
+	c := NewManifestCache(10, 1000)
+	dgst := digestFor("a")
+
+	c.Add(dgst, "", []byte("payload"), distribution.Descriptor{})
+	c.Remove(dgst)
+
+	if _, ok := c.Get(dgst); ok {
+		t.Fatalf("expected entry to be gone after Remove")
+	}
+	if c.size != 0 {
+		t.Errorf("got tracked size %d, want 0 after removing the only entry", c.size)
+	}
+}
+
+func TestManifestCacheRemoveMissingIsNoop(t *testing.T) {
+	c := NewManifestCache(10, 1000)
+	c.Remove(digestFor("does-not-exist"))
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ManifestHandler knows how to validate, describe and persist metadata for
+// one concrete manifest schema/media type. manifestService delegates all of
+// the schema-specific work to it so that Put/Get stay schema agnostic.
+type ManifestHandler interface {
+	// Manifest returns the manifest this handler was built for.
+	Manifest() distribution.Manifest
+
+	// Payload returns the manifest's media type together with its raw and
+	// canonical (content-addressable) encodings.
+	Payload() (mediaType string, payload []byte, canonical []byte, err error)
+
+	// Verify checks that the manifest is well formed and, unless
+	// skipDependencyVerification is set, that everything it directly
+	// references (blobs) exists in the repository.
+	Verify(ctx context.Context, skipDependencyVerification bool) error
+
+	// FillImageMetadata extracts as much information as the schema allows
+	// from the manifest and records it on image.
+	FillImageMetadata(ctx context.Context, image *imageapi.Image) error
+}
+
+// childManifestsProvider is implemented by ManifestHandlers whose manifest
+// fans out to other manifests (manifest lists, OCI image indexes) so that
+// Put can import those children alongside the manifest itself.
+type childManifestsProvider interface {
+	referencedDigests() []digest.Digest
+}
+
+// NewManifestHandler returns the ManifestHandler able to deal with the
+// concrete type of manifest being pushed or pulled.
+func NewManifestHandler(repo *repository, manifest distribution.Manifest) (ManifestHandler, error) {
+	switch t := manifest.(type) {
+	case *schema1.SignedManifest:
+		return &schema1ManifestHandler{repo: repo, manifest: t}, nil
+	case *schema2.DeserializedManifest:
+		return &schema2ManifestHandler{repo: repo, manifest: t}, nil
+	case *manifestlist.DeserializedManifestList:
+		return &manifestListHandler{repo: repo, manifest: t}, nil
+	case *ocischema.DeserializedManifest:
+		return &ociManifestHandler{repo: repo, manifest: t}, nil
+	default:
+		return nil, fmt.Errorf("unsupported manifest type %T", manifest)
+	}
+}
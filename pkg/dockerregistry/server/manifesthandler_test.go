@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func TestNewManifestHandlerDispatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		manifest distribution.Manifest
+		want     ManifestHandler
+	}{
+		{"schema1", &schema1.SignedManifest{}, &schema1ManifestHandler{}},
+		{"schema2", &schema2.DeserializedManifest{}, &schema2ManifestHandler{}},
+		{"manifestlist", &manifestlist.DeserializedManifestList{}, &manifestListHandler{}},
+		{"ocischema", &ocischema.DeserializedManifest{}, &ociManifestHandler{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := NewManifestHandler(nil, tc.manifest)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, want := typeName(h), typeName(tc.want)
+			if got != want {
+				t.Errorf("got handler type %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNewManifestHandlerUnsupportedType(t *testing.T) {
+	if _, err := NewManifestHandler(nil, nil); err == nil {
+		t.Fatalf("expected an error for an unsupported manifest type")
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case *schema1ManifestHandler:
+		return "schema1ManifestHandler"
+	case *schema2ManifestHandler:
+		return "schema2ManifestHandler"
+	case *manifestListHandler:
+		return "manifestListHandler"
+	case *ociManifestHandler:
+		return "ociManifestHandler"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ociImageIndexMediaType is the OCI equivalent of
+// manifestlist.MediaTypeManifestList. The two are wire-compatible, so both
+// are deserialized into manifestlist.DeserializedManifestList and handled
+// here; only the media type recorded on Payload differs.
+const ociImageIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+// manifestListMediaTypes are the media types manifestListHandler is
+// responsible for.
+var manifestListMediaTypes = []string{
+	manifestlist.MediaTypeManifestList,
+	ociImageIndexMediaType,
+}
+
+// init registers ociImageIndexMediaType with distribution.UnmarshalManifest
+// so that a client pushing/pulling an OCI image index reaches
+// manifestListHandler at all. manifestlist.MediaTypeManifestList is already
+// registered by the manifestlist package's own init(); the two schemas are
+// wire-compatible, so the same unmarshal func backs both.
+func init() {
+	unmarshalFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := &manifestlist.DeserializedManifestList{}
+		if err := m.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+		return m, distribution.Descriptor{
+			MediaType: ociImageIndexMediaType,
+			Digest:    digest.FromBytes(b),
+			Size:      int64(len(b)),
+		}, nil
+	}
+	if err := distribution.RegisterManifestSchema(ociImageIndexMediaType, unmarshalFunc); err != nil {
+		panic(fmt.Sprintf("unable to register OCI image index manifest schema: %v", err))
+	}
+}
+
+// manifestListAnnotationPrefix namespaces the annotations FillImageMetadata
+// records on the parent Image of a manifest list, one group per referenced
+// manifest.
+const manifestListAnnotationPrefix = "image.openshift.io/manifestlist"
+
+type manifestListHandler struct {
+	repo     *repository
+	manifest *manifestlist.DeserializedManifestList
+}
+
+var _ ManifestHandler = &manifestListHandler{}
+
+func (h *manifestListHandler) Manifest() distribution.Manifest {
+	return h.manifest
+}
+
+func (h *manifestListHandler) Payload() (mediaType string, payload []byte, canonical []byte, err error) {
+	mediaType, canonical, err = h.manifest.Payload()
+	return mediaType, canonical, canonical, err
+}
+
+// Verify only rejects an empty list. Existence of the referenced manifests
+// is checked by manifestService.Put directly against m.manifests, since
+// that is the store the list's own members live in, not a blob store this
+// handler has access to.
+func (h *manifestListHandler) Verify(ctx context.Context, skipDependencyVerification bool) error {
+	if len(h.manifest.Manifests) == 0 {
+		return distribution.ErrManifestVerification{fmt.Errorf("manifest list has no manifests")}
+	}
+	return nil
+}
+
+// referencedDigests returns the digest of every manifest this list fans out
+// to, so that Put can import each of them alongside the list itself.
+func (h *manifestListHandler) referencedDigests() []digest.Digest {
+	digests := make([]digest.Digest, len(h.manifest.Manifests))
+	for i, m := range h.manifest.Manifests {
+		digests[i] = m.Digest
+	}
+	return digests
+}
+
+// FillImageMetadata records the digest and platform of every manifest the
+// list references as annotations on the parent Image. A manifest list has
+// no layers or runtime config of its own: those live on the per-platform
+// child Images that are imported alongside it.
+func (h *manifestListHandler) FillImageMetadata(ctx context.Context, image *imageapi.Image) error {
+	if image.Annotations == nil {
+		image.Annotations = make(map[string]string)
+	}
+
+	for i, m := range h.manifest.Manifests {
+		prefix := fmt.Sprintf("%s.%d", manifestListAnnotationPrefix, i)
+		image.Annotations[prefix+".digest"] = m.Digest.String()
+		image.Annotations[prefix+".os"] = m.Platform.OS
+		image.Annotations[prefix+".architecture"] = m.Platform.Architecture
+		if m.Platform.Variant != "" {
+			image.Annotations[prefix+".variant"] = m.Platform.Variant
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestManifestListHandlerVerifyRejectsEmptyList(t *testing.T) {
+	h := &manifestListHandler{manifest: &manifestlist.DeserializedManifestList{}}
+
+	if err := h.Verify(context.Background(), false); err == nil {
+		t.Fatalf("expected an error for a manifest list with no manifests")
+	}
+}
+
+func TestManifestListHandlerVerifyAcceptsNonEmptyList(t *testing.T) {
+	h := &manifestListHandler{manifest: &manifestlist.DeserializedManifestList{
+		Manifests: []manifestlist.ManifestDescriptor{{}},
+	}}
+
+	if err := h.Verify(context.Background(), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestManifestListHandlerReferencedDigests(t *testing.T) {
+	d1 := digest.FromBytes([]byte("a"))
+	d2 := digest.FromBytes([]byte("b"))
+	h := &manifestListHandler{manifest: &manifestlist.DeserializedManifestList{
+		Manifests: []manifestlist.ManifestDescriptor{
+			{Descriptor: distribution.Descriptor{Digest: d1}},
+			{Descriptor: distribution.Descriptor{Digest: d2}},
+		},
+	}}
+
+	got := h.referencedDigests()
+	if len(got) != 2 || got[0] != d1 || got[1] != d2 {
+		t.Errorf("got %v, want [%s %s]", got, d1, d2)
+	}
+}
+
+func TestManifestListHandlerFillImageMetadata(t *testing.T) {
+	d1 := digest.FromBytes([]byte("a"))
+	h := &manifestListHandler{manifest: &manifestlist.DeserializedManifestList{
+		Manifests: []manifestlist.ManifestDescriptor{
+			{
+				Descriptor: distribution.Descriptor{Digest: d1},
+				Platform: manifestlist.PlatformSpec{
+					OS:           "linux",
+					Architecture: "amd64",
+				},
+			},
+		},
+	}}
+
+	image := &imageapi.Image{}
+	if err := h.FillImageMetadata(context.Background(), image); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"image.openshift.io/manifestlist.0.digest":       d1.String(),
+		"image.openshift.io/manifestlist.0.os":           "linux",
+		"image.openshift.io/manifestlist.0.architecture": "amd64",
+	}
+	for k, v := range want {
+		if image.Annotations[k] != v {
+			t.Errorf("annotation %s = %q, want %q", k, image.Annotations[k], v)
+		}
+	}
+	if _, ok := image.Annotations["image.openshift.io/manifestlist.0.variant"]; ok {
+		t.Errorf("did not expect a variant annotation when Platform.Variant is empty")
+	}
+}
@@ -2,17 +2,22 @@ package server
 
 import (
 	"fmt"
-	"net/http"
-	"strings"
+	"runtime"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/notifications"
 	regapi "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/uuid"
 
 	kapi "k8s.io/kubernetes/pkg/api"
 	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
 
 	imageapi "github.com/openshift/origin/pkg/image/api"
 	quotautil "github.com/openshift/origin/pkg/quota/util"
@@ -27,6 +32,22 @@ type manifestService struct {
 
 	// acceptschema2 allows to refuse the manifest schema version 2
 	acceptschema2 bool
+
+	// acceptmanifestlist allows to refuse manifest lists / OCI image indexes
+	acceptmanifestlist bool
+
+	// acceptoci allows to refuse OCI image manifests and artifacts
+	acceptoci bool
+
+	// cache is the (optional) process-wide manifest cache shared by every
+	// repository served by this registry. It may be nil, in which case Get
+	// and Put behave as if no cache existed.
+	cache *ManifestCache
+
+	// notifications delivers webhook events for every manifest push and
+	// delete. It may be nil, in which case Put and Delete simply don't emit
+	// any events.
+	notifications *NotificationListener
 }
 
 // Exists returns true if the manifest specified by dgst exists.
@@ -69,12 +90,35 @@ func (m *manifestService) Get(ctx context.Context, dgst digest.Digest, options .
 		ref = ref.DockerClientDefaults().AsRepository()
 	}
 
+	if m.cache != nil {
+		if cached, ok := m.cache.Get(dgst); ok {
+			manifest, _, err := distribution.UnmarshalManifest(cached.mediaType, cached.payload)
+			if err != nil {
+				manifestCacheMisses.Inc()
+				context.GetLogger(ctx).Errorf("error unmarshaling cached manifest %s: %v", dgst, err)
+			} else {
+				manifestCacheHits.Inc()
+				m.repo.rememberLayersOfManifest(dgst, manifest, ref.Exact())
+				if ml, ok := manifest.(*manifestlist.DeserializedManifestList); ok {
+					return m.resolveManifestList(ctx, ml, options...)
+				}
+				return manifest, nil
+			}
+		} else {
+			manifestCacheMisses.Inc()
+		}
+	}
+
 	manifest, err := m.manifests.Get(WithRepository(ctx, m.repo), dgst, options...)
 	switch err.(type) {
 	case distribution.ErrManifestUnknownRevision:
 		break
 	case nil:
 		m.repo.rememberLayersOfManifest(dgst, manifest, ref.Exact())
+		m.cacheManifest(dgst, manifest)
+		if ml, ok := manifest.(*manifestlist.DeserializedManifestList); ok {
+			return m.resolveManifestList(ctx, ml, options...)
+		}
 		return manifest, nil
 	default:
 		context.GetLogger(m.ctx).Errorf("unable to get manifest from storage: %v", err)
@@ -91,10 +135,71 @@ func (m *manifestService) Get(ctx context.Context, dgst digest.Digest, options .
 	}
 
 	manifest, err = m.repo.manifestFromImageWithCachedLayers(image, ref.Exact())
+	if err == nil {
+		m.cacheManifest(dgst, manifest)
+	}
 
 	return manifest, err
 }
 
+// cacheManifest records manifest's canonical payload in m.cache, if one is
+// configured. Failures to re-marshal the manifest just mean it won't be
+// cached; they are not propagated since the manifest itself was already
+// obtained successfully.
+func (m *manifestService) cacheManifest(dgst digest.Digest, manifest distribution.Manifest) {
+	if m.cache == nil {
+		return
+	}
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return
+	}
+	m.cache.Add(dgst, mediaType, payload, distribution.Descriptor{Digest: dgst, MediaType: mediaType, Size: int64(len(payload))})
+}
+
+// resolveManifestList returns ml verbatim if the client's Accept header
+// (carried in options) includes ml's own media type, matching upstream
+// registry behavior for fat manifests. Otherwise it
+// falls back to the child manifest matching the server's own platform, the
+// way clients predating manifest lists expect.
+func (m *manifestService) resolveManifestList(ctx context.Context, ml *manifestlist.DeserializedManifestList, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	mediaType, canonical, err := ml.Payload()
+	if err != nil {
+		return nil, err
+	}
+	if acceptsMediaType(options, mediaType) {
+		return ml, nil
+	}
+
+	for _, desc := range ml.Manifests {
+		if desc.Platform.OS == runtime.GOOS && desc.Platform.Architecture == runtime.GOARCH {
+			return m.Get(ctx, desc.Digest, options...)
+		}
+	}
+
+	return nil, distribution.ErrManifestUnknownRevision{
+		Name:     m.repo.Named().Name(),
+		Revision: digest.FromBytes(canonical),
+	}
+}
+
+// acceptsMediaType reports whether any distribution.ManifestMediaTypesOption
+// among options lists mediaType as acceptable.
+func acceptsMediaType(options []distribution.ManifestServiceOption, mediaType string) bool {
+	for _, option := range options {
+		opt, ok := option.(distribution.ManifestMediaTypesOption)
+		if !ok {
+			continue
+		}
+		for _, accepted := range opt.MediaTypes {
+			if accepted == mediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Put creates or updates the named manifest.
 func (m *manifestService) Put(ctx context.Context, manifest distribution.Manifest, options ...distribution.ManifestServiceOption) (digest.Digest, error) {
 	context.GetLogger(ctx).Debugf("(*manifestService).Put")
@@ -112,12 +217,32 @@ func (m *manifestService) Put(ctx context.Context, manifest distribution.Manifes
 	if !m.acceptschema2 && mediaType == schema2.MediaTypeManifest {
 		return "", regapi.ErrorCodeManifestInvalid.WithDetail(fmt.Errorf("manifest V2 schema 2 not allowed"))
 	}
+	if !m.acceptmanifestlist && (mediaType == manifestlist.MediaTypeManifestList || mediaType == ociImageIndexMediaType) {
+		return "", regapi.ErrorCodeManifestInvalid.WithDetail(fmt.Errorf("manifest lists not allowed"))
+	}
+	if !m.acceptoci && mediaType == ocischema.MediaTypeManifest {
+		return "", regapi.ErrorCodeManifestInvalid.WithDetail(fmt.Errorf("OCI image manifests not allowed"))
+	}
 
 	// in order to stat the referenced blobs, repository need to be set on the context
 	if err := mh.Verify(WithRepository(ctx, m.repo), false); err != nil {
 		return "", err
 	}
 
+	// a manifest list only references other manifests, never blobs, so its
+	// dependencies are checked against m.manifests instead of mh.Verify
+	if ml, ok := manifest.(*manifestlist.DeserializedManifestList); ok {
+		for _, desc := range ml.Manifests {
+			exists, err := m.manifests.Exists(WithRepository(ctx, m.repo), desc.Digest)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return "", regapi.ErrorCodeManifestBlobUnknown.WithDetail(fmt.Errorf("referenced manifest %s does not exist", desc.Digest))
+			}
+		}
+	}
+
 	_, err = m.manifests.Put(WithRepository(ctx, m.repo), manifest, options...)
 	if err != nil {
 		return "", err
@@ -126,91 +251,180 @@ func (m *manifestService) Put(ctx context.Context, manifest distribution.Manifes
 	// Calculate digest
 	dgst := digest.FromBytes(canonical)
 
-	// Upload to openshift
-	ism := imageapi.ImageStreamMapping{
+	if m.cache != nil {
+		m.cache.Add(dgst, mediaType, canonical, distribution.Descriptor{Digest: dgst, MediaType: mediaType, Size: int64(len(canonical))})
+	}
+
+	image := imageapi.Image{
 		ObjectMeta: kapi.ObjectMeta{
-			Namespace: m.repo.namespace,
-			Name:      m.repo.name,
-		},
-		Image: imageapi.Image{
-			ObjectMeta: kapi.ObjectMeta{
-				Name: dgst.String(),
-				Annotations: map[string]string{
-					imageapi.ManagedByOpenShiftAnnotation: "true",
-				},
+			Name: dgst.String(),
+			Annotations: map[string]string{
+				imageapi.ManagedByOpenShiftAnnotation: "true",
 			},
-			DockerImageReference:         fmt.Sprintf("%s/%s/%s@%s", m.repo.registryAddr, m.repo.namespace, m.repo.name, dgst.String()),
-			DockerImageManifest:          string(payload),
-			DockerImageManifestMediaType: mediaType,
 		},
+		DockerImageReference:         fmt.Sprintf("%s/%s/%s@%s", m.repo.registryAddr, m.repo.namespace, m.repo.name, dgst.String()),
+		DockerImageManifest:          string(payload),
+		DockerImageManifestMediaType: mediaType,
 	}
 
+	var tag string
 	for _, option := range options {
 		if opt, ok := option.(distribution.WithTagOption); ok {
-			ism.Tag = opt.Tag
+			tag = opt.Tag
 			break
 		}
 	}
 
-	if err = mh.FillImageMetadata(ctx, &ism.Image); err != nil {
+	if err = mh.FillImageMetadata(ctx, &image); err != nil {
 		return "", err
 	}
 
 	// Remove the raw manifest as it's very big and this leads to a large memory consumption in etcd.
-	ism.Image.DockerImageManifest = ""
-	ism.Image.DockerImageConfig = ""
+	image.DockerImageManifest = ""
+	image.DockerImageConfig = ""
+
+	// The primary entry describes the manifest that was just pushed; it
+	// always comes first so its status can be read back at isi.Status.Images[0].
+	images := []imageapi.ImageImportSpec{
+		{
+			From: kapi.ObjectReference{
+				Kind: "DockerImage",
+				Name: image.DockerImageReference,
+			},
+			To:              tagReference(tag),
+			IncludeManifest: true,
+		},
+	}
 
-	if err = m.repo.registryOSClient.ImageStreamMappings(m.repo.namespace).Create(&ism); err != nil {
-		// if the error was that the image stream wasn't found, try to auto provision it
-		statusErr, ok := err.(*kerrors.StatusError)
-		if !ok {
-			context.GetLogger(ctx).Errorf("error creating ImageStreamMapping: %s", err)
-			return "", err
+	// A manifest list fans out to per-platform manifests that were pushed
+	// earlier as their own, untagged images; import them alongside the list
+	// itself so the whole set is recorded in a single API call.
+	if ml, ok := mh.(childManifestsProvider); ok {
+		for _, childDigest := range ml.referencedDigests() {
+			images = append(images, imageapi.ImageImportSpec{
+				From: kapi.ObjectReference{
+					Kind: "DockerImage",
+					Name: fmt.Sprintf("%s/%s/%s@%s", m.repo.registryAddr, m.repo.namespace, m.repo.name, childDigest.String()),
+				},
+				IncludeManifest: true,
+			})
 		}
+	}
 
-		if quotautil.IsErrorQuotaExceeded(statusErr) {
-			context.GetLogger(ctx).Errorf("denied creating ImageStreamMapping: %v", statusErr)
+	// Upload to openshift. ImageStreamImport both persists the image and,
+	// unlike ImageStreamMapping, implicitly creates the backing ImageStream
+	// when it doesn't exist yet, so there is no need to auto-provision one
+	// on a 404 any more.
+	isi := &imageapi.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace: m.repo.namespace,
+			Name:      m.repo.name,
+		},
+		Spec: imageapi.ImageStreamImportSpec{
+			Import: true,
+			Images: images,
+		},
+	}
+
+	isi, err = m.repo.registryOSClient.ImageStreamImports(m.repo.namespace).Create(isi)
+	if err != nil {
+		if quotautil.IsErrorQuotaExceeded(err) {
+			context.GetLogger(ctx).Errorf("denied creating ImageStreamImport: %v", err)
 			return "", distribution.ErrAccessDenied
 		}
+		context.GetLogger(ctx).Errorf("error creating ImageStreamImport: %s", err)
+		return "", err
+	}
 
-		status := statusErr.ErrStatus
-		if status.Code != http.StatusNotFound ||
-			(strings.ToLower(status.Details.Kind) != "imagestream" /*pre-1.2*/ && strings.ToLower(status.Details.Kind) != "imagestreams") ||
-			status.Details.Name != m.repo.name {
-			context.GetLogger(ctx).Errorf("error creating ImageStreamMapping: %s", err)
-			return "", err
-		}
+	if len(isi.Status.Images) != len(images) {
+		context.GetLogger(ctx).Errorf("unexpected number of image statuses in ImageStreamImport response for %s: got %d, want %d", image.DockerImageReference, len(isi.Status.Images), len(images))
+		return "", regapi.ErrorCodeManifestInvalid.WithDetail(fmt.Errorf("unexpected ImageStreamImport response for %s", image.DockerImageReference))
+	}
 
-		stream := imageapi.ImageStream{}
-		stream.Name = m.repo.name
+	// Status.Images[0] always corresponds to the manifest that was just
+	// pushed; failures importing the already-existing children it
+	// references are not fatal to this push.
+	if status := isi.Status.Images[0].Status; status.Status != unversioned.StatusSuccess {
+		return "", imageImportStatusError(ctx, status)
+	}
 
-		uclient, ok := UserClientFrom(m.ctx)
-		if !ok {
-			context.GetLogger(ctx).Errorf("error creating user client to auto provision image stream: Origin user client unavailable")
-			return "", statusErr
-		}
+	m.notifyManifestEvent(ctx, notifications.EventActionPush, mediaType, canonical, dgst, tag)
 
-		if _, err := uclient.ImageStreams(m.repo.namespace).Create(&stream); err != nil {
-			if quotautil.IsErrorQuotaExceeded(err) {
-				context.GetLogger(ctx).Errorf("denied creating ImageStream: %v", err)
-				return "", distribution.ErrAccessDenied
-			}
-			context.GetLogger(ctx).Errorf("error auto provisioning ImageStream: %s", err)
-			return "", statusErr
-		}
+	return dgst, nil
+}
 
-		// try to create the ISM again
-		if err := m.repo.registryOSClient.ImageStreamMappings(m.repo.namespace).Create(&ism); err != nil {
-			if quotautil.IsErrorQuotaExceeded(err) {
-				context.GetLogger(ctx).Errorf("denied a creation of ImageStreamMapping: %v", err)
-				return "", distribution.ErrAccessDenied
-			}
-			context.GetLogger(ctx).Errorf("error creating ImageStreamMapping: %s", err)
-			return "", err
+// notifyManifestEvent synthesizes a notifications.Event describing a
+// manifest push or delete and hands it to m.notifications. The event
+// carries the canonical descriptor of the manifest, a manifest-URL for the
+// repository, the authenticated user that triggered it (if any) and, for a
+// push, the tag it was pushed to.
+func (m *manifestService) notifyManifestEvent(ctx context.Context, action string, mediaType string, canonical []byte, dgst digest.Digest, tag string) {
+	if m.notifications == nil {
+		return
+	}
+
+	event := notifications.Event{
+		ID:        uuid.Generate().String(),
+		Timestamp: time.Now(),
+		Action:    action,
+		Target: &notifications.Target{
+			Descriptor: distribution.Descriptor{
+				MediaType: mediaType,
+				Digest:    dgst,
+				Size:      int64(len(canonical)),
+			},
+			Repository: fmt.Sprintf("%s/%s", m.repo.namespace, m.repo.name),
+			URL:        buildManifestURL(m.repo, dgst),
+			Tag:        tag,
+		},
+	}
+
+	if uclient, ok := UserClientFrom(m.ctx); ok {
+		if userInfo, err := uclient.Users().Get("~"); err == nil {
+			event.Actor.Name = userInfo.Name
 		}
 	}
 
-	return dgst, nil
+	if err := m.notifications.Write(event); err != nil {
+		context.GetLogger(ctx).Errorf("error queuing %s notification for %s@%s: %v", action, m.repo.Named().Name(), dgst, err)
+	}
+}
+
+// buildManifestURL returns the pull URL clients use to fetch dgst from
+// repo, the same shape a v2.URLBuilder would produce for a manifest route.
+func buildManifestURL(repo *repository, dgst digest.Digest) string {
+	return fmt.Sprintf("https://%s/v2/%s/%s/manifests/%s", repo.registryAddr, repo.namespace, repo.name, dgst.String())
+}
+
+// tagReference returns the destination of an ImageImportSpec for tag, or
+// nil when the manifest was pushed by digest only.
+func tagReference(tag string) *kapi.LocalObjectReference {
+	if tag == "" {
+		return nil
+	}
+	return &kapi.LocalObjectReference{Name: tag}
+}
+
+// imageImportStatusError translates the per-image unversioned.Status carried
+// by an ImageStreamImport response into the errors distribution/registry
+// handlers already know how to render to clients.
+func imageImportStatusError(ctx context.Context, status unversioned.Status) error {
+	err := kerrors.FromObject(&status)
+
+	switch {
+	case quotautil.IsErrorQuotaExceeded(err):
+		context.GetLogger(ctx).Errorf("denied creating image via ImageStreamImport: %v", err)
+		return distribution.ErrAccessDenied
+	case kerrors.IsForbidden(err):
+		context.GetLogger(ctx).Errorf("denied creating image via ImageStreamImport: %v", err)
+		return distribution.ErrAccessDenied
+	case kerrors.IsInvalid(err):
+		context.GetLogger(ctx).Errorf("invalid image import: %v", err)
+		return regapi.ErrorCodeManifestInvalid.WithDetail(err)
+	default:
+		context.GetLogger(ctx).Errorf("error importing image: %v", err)
+		return err
+	}
 }
 
 // Delete deletes the manifest with digest `dgst`. Note: Image resources
@@ -218,5 +432,24 @@ func (m *manifestService) Put(ctx context.Context, manifest distribution.Manifes
 // the content related to the manifest in the registry's storage (signatures).
 func (m *manifestService) Delete(ctx context.Context, dgst digest.Digest) error {
 	context.GetLogger(ctx).Debugf("(*manifestService).Delete")
-	return m.manifests.Delete(WithRepository(ctx, m.repo), dgst)
-}
\ No newline at end of file
+
+	// Best effort: grab the canonical descriptor before it's gone so the
+	// delete notification can carry it too, same as the push notification.
+	var mediaType string
+	var canonical []byte
+	if manifest, err := m.manifests.Get(WithRepository(ctx, m.repo), dgst); err == nil {
+		mediaType, canonical, _ = manifest.Payload()
+	}
+
+	if err := m.manifests.Delete(WithRepository(ctx, m.repo), dgst); err != nil {
+		return err
+	}
+
+	if m.cache != nil {
+		m.cache.Remove(dgst)
+	}
+
+	m.notifyManifestEvent(ctx, notifications.EventActionDelete, mediaType, canonical, dgst, "")
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+func TestAcceptsMediaType(t *testing.T) {
+	options := []distribution.ManifestServiceOption{
+		distribution.WithTagOption{Tag: "latest"},
+		distribution.ManifestMediaTypesOption{MediaTypes: []string{"application/vnd.docker.distribution.manifest.list.v2+json"}},
+	}
+
+	if !acceptsMediaType(options, "application/vnd.docker.distribution.manifest.list.v2+json") {
+		t.Errorf("expected the listed media type to be accepted")
+	}
+	if acceptsMediaType(options, "application/vnd.docker.distribution.manifest.v2+json") {
+		t.Errorf("did not expect an unlisted media type to be accepted")
+	}
+	if acceptsMediaType(nil, "application/vnd.docker.distribution.manifest.v2+json") {
+		t.Errorf("expected no options to accept nothing")
+	}
+}
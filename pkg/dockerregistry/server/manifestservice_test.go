@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	regapi "github.com/docker/distribution/registry/api/v2"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+func TestTagReference(t *testing.T) {
+	if ref := tagReference(""); ref != nil {
+		t.Errorf("expected a nil reference for an empty tag, got %v", ref)
+	}
+	if ref := tagReference("latest"); ref == nil || ref.Name != "latest" {
+		t.Errorf("got %v, want a reference to %q", ref, "latest")
+	}
+}
+
+func TestImageImportStatusErrorForbidden(t *testing.T) {
+	status := unversioned.Status{
+		Status:  unversioned.StatusFailure,
+		Reason:  unversioned.StatusReasonForbidden,
+		Message: "denied",
+	}
+
+	err := imageImportStatusError(context.Background(), status)
+	if err != distribution.ErrAccessDenied {
+		t.Errorf("got %v, want distribution.ErrAccessDenied", err)
+	}
+}
+
+func TestImageImportStatusErrorInvalid(t *testing.T) {
+	status := unversioned.Status{
+		Status:  unversioned.StatusFailure,
+		Reason:  unversioned.StatusReasonInvalid,
+		Message: "bad manifest",
+	}
+
+	err := imageImportStatusError(context.Background(), status)
+	ec, ok := err.(errcode.Error)
+	if !ok {
+		t.Fatalf("got %T, want errcode.Error", err)
+	}
+	if ec.Code != regapi.ErrorCodeManifestInvalid {
+		t.Errorf("got code %v, want %v", ec.Code, regapi.ErrorCodeManifestInvalid)
+	}
+}
+
+func TestImageImportStatusErrorDefault(t *testing.T) {
+	status := unversioned.Status{
+		Status:  unversioned.StatusFailure,
+		Reason:  unversioned.StatusReasonUnknown,
+		Message: "boom",
+	}
+
+	err := imageImportStatusError(context.Background(), status)
+	if err == nil {
+		t.Fatalf("expected a non-nil error")
+	}
+	if _, ok := err.(errcode.Error); ok {
+		t.Errorf("did not expect a typed errcode.Error for an unrecognized status reason")
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/docker/distribution/notifications"
+)
+
+// NotificationEndpointConfig describes one webhook receiver to notify about
+// manifest pushes and deletes. It mirrors one entry of the
+// `notifications.endpoints` section of the registry config file.
+type NotificationEndpointConfig struct {
+	Name      string
+	URL       string
+	Headers   http.Header
+	Timeout   time.Duration
+	Threshold int
+	Backoff   time.Duration
+}
+
+// NotificationListener fans manifest push/delete events out to every
+// configured webhook endpoint. Each endpoint gets its own retrying queue
+// (provided by notifications.Endpoint), so a slow or unreachable receiver
+// only ever backs up its own queue, never the push that produced the event.
+// The queue has no size limit: events for a receiver that stays unreachable
+// accumulate in memory.
+type NotificationListener struct {
+	sink notifications.Sink
+}
+
+// NewNotificationListener builds a NotificationListener from the
+// notifications.endpoints section of the registry configuration. A
+// NotificationListener with no endpoints configured is valid: it simply
+// drops every event it is given.
+func NewNotificationListener(endpoints []NotificationEndpointConfig) *NotificationListener {
+	sinks := make([]notifications.Sink, 0, len(endpoints))
+	for _, e := range endpoints {
+		sinks = append(sinks, notifications.NewEndpoint(e.Name, e.URL, notifications.EndpointConfig{
+			Timeout:   e.Timeout,
+			Threshold: e.Threshold,
+			Backoff:   e.Backoff,
+			Headers:   e.Headers,
+		}))
+	}
+	return &NotificationListener{sink: notifications.NewBroadcaster(sinks...)}
+}
+
+// Write delivers event to every configured endpoint's queue. A nil
+// NotificationListener is valid and simply drops the event, so callers
+// don't need to guard every call site with a nil check.
+func (l *NotificationListener) Write(event notifications.Event) error {
+	if l == nil || l.sink == nil {
+		return nil
+	}
+	return l.sink.Write(event)
+}
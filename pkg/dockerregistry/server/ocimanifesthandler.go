@@ -0,0 +1,100 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/ocischema"
+
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+type ociManifestHandler struct {
+	repo     *repository
+	manifest *ocischema.DeserializedManifest
+}
+
+var _ ManifestHandler = &ociManifestHandler{}
+
+func (h *ociManifestHandler) Manifest() distribution.Manifest {
+	return h.manifest
+}
+
+func (h *ociManifestHandler) Payload() (mediaType string, payload []byte, canonical []byte, err error) {
+	mediaType, canonical, err = h.manifest.Payload()
+	return mediaType, canonical, canonical, err
+}
+
+func (h *ociManifestHandler) Verify(ctx context.Context, skipDependencyVerification bool) error {
+	if skipDependencyVerification {
+		return nil
+	}
+
+	var errs distribution.ErrManifestVerification
+
+	if _, err := h.repo.Blobs(ctx).Stat(ctx, h.manifest.Config.Digest); err != nil {
+		if err != distribution.ErrBlobUnknown {
+			errs = append(errs, err)
+		}
+		errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: h.manifest.Config.Digest})
+	}
+
+	for _, layer := range h.manifest.Layers {
+		if isNonDistributableLayer(layer.MediaType) {
+			// Non-distributable layers are fetched by the client from the
+			// URLs carried on the descriptor, not stored in this registry.
+			continue
+		}
+		if _, err := h.repo.Blobs(ctx).Stat(ctx, layer.Digest); err != nil {
+			if err != distribution.ErrBlobUnknown {
+				errs = append(errs, err)
+			}
+			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: layer.Digest})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// FillImageMetadata populates image from the OCI manifest. When the
+// manifest's config has the well-known OCI image config media type, the
+// config blob is fetched and parsed the same way a schema2 image's is. Any
+// other config media type marks this as an OCI artifact (a Helm chart, an
+// SBOM, a cosign signature, ...): the layer descriptors are still recorded,
+// but the config is left unparsed so the push isn't rejected just because
+// the registry doesn't understand its payload.
+func (h *ociManifestHandler) FillImageMetadata(ctx context.Context, image *imageapi.Image) error {
+	image.DockerImageManifestMediaType = ocischema.MediaTypeManifest
+
+	image.DockerImageLayers = make([]imageapi.ImageLayer, len(h.manifest.Layers))
+	for i, layer := range h.manifest.Layers {
+		image.DockerImageLayers[i].MediaType = layer.MediaType
+		image.DockerImageLayers[i].Name = layer.Digest.String()
+		image.DockerImageLayers[i].LayerSize = layer.Size
+	}
+
+	if h.manifest.Config.MediaType != ispec.MediaTypeImageConfig {
+		return nil
+	}
+
+	config, err := h.repo.Blobs(ctx).Get(ctx, h.manifest.Config.Digest)
+	if err != nil {
+		return err
+	}
+	image.DockerImageConfig = string(config)
+
+	return imageapi.ImageWithMetadata(image)
+}
+
+// isNonDistributableLayer reports whether mediaType identifies a foreign /
+// non-distributable layer, which clients fetch directly from the URLs on
+// its descriptor rather than from this registry's blob storage.
+func isNonDistributableLayer(mediaType string) bool {
+	return strings.Contains(mediaType, ".nondistributable.")
+}
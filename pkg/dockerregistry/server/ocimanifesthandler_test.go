@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/context"
+)
+
+func TestIsNonDistributableLayer(t *testing.T) {
+	for _, tc := range []struct {
+		mediaType string
+		want      bool
+	}{
+		{"application/vnd.docker.image.rootfs.diff.tar.gzip", false},
+		{"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip.nondistributable", true},
+		{"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip", true},
+		{"application/vnd.oci.image.layer.v1.tar+gzip", false},
+	} {
+		if got := isNonDistributableLayer(tc.mediaType); got != tc.want {
+			t.Errorf("isNonDistributableLayer(%q) = %v, want %v", tc.mediaType, got, tc.want)
+		}
+	}
+}
+
+// Verify must not touch h.repo when skipDependencyVerification is set, since
+// callers that already trust the manifest may not have a repository
+// available.
+func TestOCIManifestHandlerVerifySkipsDependencyCheck(t *testing.T) {
+	h := &ociManifestHandler{}
+
+	if err := h.Verify(context.Background(), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
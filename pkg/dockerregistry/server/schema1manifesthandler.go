@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/schema1"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+type schema1ManifestHandler struct {
+	repo     *repository
+	manifest *schema1.SignedManifest
+}
+
+var _ ManifestHandler = &schema1ManifestHandler{}
+
+func (h *schema1ManifestHandler) Manifest() distribution.Manifest {
+	return h.manifest
+}
+
+func (h *schema1ManifestHandler) Payload() (mediaType string, payload []byte, canonical []byte, err error) {
+	_, payload, err = h.manifest.Payload()
+	return schema1.MediaTypeManifest, payload, h.manifest.Canonical, err
+}
+
+func (h *schema1ManifestHandler) Verify(ctx context.Context, skipDependencyVerification bool) error {
+	if skipDependencyVerification {
+		return nil
+	}
+
+	var errs distribution.ErrManifestVerification
+	for _, fsLayer := range h.manifest.FSLayers {
+		if _, err := h.repo.Blobs(ctx).Stat(ctx, fsLayer.BlobSum); err != nil {
+			if err != distribution.ErrBlobUnknown {
+				errs = append(errs, err)
+			}
+			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: fsLayer.BlobSum})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (h *schema1ManifestHandler) FillImageMetadata(ctx context.Context, image *imageapi.Image) error {
+	return imageapi.ImageWithMetadata(image)
+}
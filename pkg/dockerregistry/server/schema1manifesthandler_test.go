@@ -0,0 +1,16 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/context"
+)
+
+// Verify must not touch h.repo when skipDependencyVerification is set.
+func TestSchema1ManifestHandlerVerifySkipsDependencyCheck(t *testing.T) {
+	h := &schema1ManifestHandler{}
+
+	if err := h.Verify(context.Background(), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
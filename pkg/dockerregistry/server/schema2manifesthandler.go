@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/schema2"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+type schema2ManifestHandler struct {
+	repo     *repository
+	manifest *schema2.DeserializedManifest
+}
+
+var _ ManifestHandler = &schema2ManifestHandler{}
+
+func (h *schema2ManifestHandler) Manifest() distribution.Manifest {
+	return h.manifest
+}
+
+func (h *schema2ManifestHandler) Payload() (mediaType string, payload []byte, canonical []byte, err error) {
+	mediaType, canonical, err = h.manifest.Payload()
+	return mediaType, canonical, canonical, err
+}
+
+func (h *schema2ManifestHandler) Verify(ctx context.Context, skipDependencyVerification bool) error {
+	if skipDependencyVerification {
+		return nil
+	}
+
+	var errs distribution.ErrManifestVerification
+
+	if _, err := h.repo.Blobs(ctx).Stat(ctx, h.manifest.Config.Digest); err != nil {
+		if err != distribution.ErrBlobUnknown {
+			errs = append(errs, err)
+		}
+		errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: h.manifest.Config.Digest})
+	}
+
+	for _, layer := range h.manifest.Layers {
+		if _, err := h.repo.Blobs(ctx).Stat(ctx, layer.Digest); err != nil {
+			if err != distribution.ErrBlobUnknown {
+				errs = append(errs, err)
+			}
+			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: layer.Digest})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (h *schema2ManifestHandler) FillImageMetadata(ctx context.Context, image *imageapi.Image) error {
+	image.DockerImageManifestMediaType = schema2.MediaTypeManifest
+
+	image.DockerImageLayers = make([]imageapi.ImageLayer, len(h.manifest.Layers))
+	for i, layer := range h.manifest.Layers {
+		image.DockerImageLayers[i].MediaType = layer.MediaType
+		image.DockerImageLayers[i].Name = layer.Digest.String()
+		image.DockerImageLayers[i].LayerSize = layer.Size
+	}
+
+	config, err := h.repo.Blobs(ctx).Get(ctx, h.manifest.Config.Digest)
+	if err != nil {
+		return err
+	}
+	image.DockerImageConfig = string(config)
+
+	return imageapi.ImageWithMetadata(image)
+}